@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MarketDataSource feeds StockData samples for symbols to publishTick
+// until ctx is cancelled or an unrecoverable error occurs. Only the
+// elected leader instance runs a MarketDataSource; see runLeaderElection.
+type MarketDataSource interface {
+	Start(ctx context.Context, symbols []string) error
+}
+
+// AlphaVantageSource polls the Alpha Vantage REST API on a fixed timer.
+type AlphaVantageSource struct{}
+
+// NewAlphaVantageSource returns the default polling data source.
+func NewAlphaVantageSource() *AlphaVantageSource {
+	return &AlphaVantageSource{}
+}
+
+// Start polls fetchStock for every symbol once immediately and then on
+// every tick of the configured poll interval, publishing results. It
+// only returns once ctx is cancelled.
+func (s *AlphaVantageSource) Start(ctx context.Context, symbols []string) error {
+	interval := getPollInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	poll := func() {
+		for _, symbol := range symbols {
+			data, ok, err := fetchCachedStock(ctx, symbol)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			publishTick(data)
+		}
+	}
+
+	poll()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			poll()
+		}
+	}
+}
+
+// alpacaSubscribe is the message sent after auth to subscribe to trades,
+// quotes and bars for the requested symbols.
+type alpacaSubscribe struct {
+	Action string   `json:"action"`
+	Trades []string `json:"trades"`
+	Quotes []string `json:"quotes"`
+	Bars   []string `json:"bars"`
+}
+
+// alpacaMessage is the minimal shape shared by every message type on the
+// Alpaca stream; T discriminates trade ("t"), quote ("q") and bar ("b")
+// payloads.
+type alpacaMessage struct {
+	Type      string  `json:"T"`
+	Symbol    string  `json:"S"`
+	Price     float64 `json:"p"`
+	BidPrice  float64 `json:"bp"`
+	AskPrice  float64 `json:"ap"`
+	Open      float64 `json:"o"`
+	Close     float64 `json:"c"`
+	Size      float64 `json:"s"`
+	Timestamp string  `json:"t"`
+}
+
+// Trade is a single executed trade on the Alpaca stream.
+type Trade struct {
+	Symbol    string
+	Price     float64
+	Size      float64
+	Timestamp time.Time
+}
+
+// Quote is a top-of-book bid/ask update on the Alpaca stream.
+type Quote struct {
+	Symbol    string
+	BidPrice  float64
+	AskPrice  float64
+	Timestamp time.Time
+}
+
+// Bar is a completed OHLC aggregate on the Alpaca stream.
+type Bar struct {
+	Symbol    string
+	Open      float64
+	Close     float64
+	Timestamp time.Time
+}
+
+// AlpacaSource streams trades, quotes and bars over a WebSocket
+// connection, authenticating with API key/secret and re-subscribing on
+// reconnect.
+type AlpacaSource struct {
+	apiKey    string
+	apiSecret string
+	wsURL     string
+}
+
+// NewAlpacaSource builds an AlpacaSource from the ALPACA_API_KEY,
+// ALPACA_API_SECRET and ALPACA_WS_URL env vars.
+func NewAlpacaSource() *AlpacaSource {
+	wsURL := strings.TrimSpace(os.Getenv("ALPACA_WS_URL"))
+	if wsURL == "" {
+		wsURL = "wss://stream.data.alpaca.markets/v2/iex"
+	}
+	return &AlpacaSource{
+		apiKey:    strings.TrimSpace(os.Getenv("ALPACA_API_KEY")),
+		apiSecret: strings.TrimSpace(os.Getenv("ALPACA_API_SECRET")),
+		wsURL:     wsURL,
+	}
+}
+
+// Start connects to the Alpaca stream, authenticates and subscribes to
+// symbols, and dispatches incoming messages as StockData. On disconnect
+// it reconnects with exponential backoff until ctx is cancelled.
+func (s *AlpacaSource) Start(ctx context.Context, symbols []string) error {
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.run(ctx, symbols); err != nil {
+			log.Println("alpaca stream error:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = time.Duration(math.Min(float64(backoff*2), float64(maxBackoff)))
+	}
+}
+
+func (s *AlpacaSource) run(ctx context.Context, symbols []string) error {
+	u, err := url.Parse(s.wsURL)
+	if err != nil {
+		return err
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// conn.ReadJSON below blocks indefinitely with no ctx awareness of its
+	// own, so close the connection out from under it on cancellation -
+	// otherwise a demoted leader keeps streaming and publishing ticks.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := conn.WriteJSON(map[string]string{
+		"action": "auth",
+		"key":    s.apiKey,
+		"secret": s.apiSecret,
+	}); err != nil {
+		return err
+	}
+
+	if err := conn.WriteJSON(alpacaSubscribe{
+		Action: "subscribe",
+		Trades: symbols,
+		Quotes: symbols,
+		Bars:   symbols,
+	}); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	prices := make(map[string]float64)
+
+	for {
+		var msgs []alpacaMessage
+		if err := conn.ReadJSON(&msgs); err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			data, ok := dispatchAlpacaMessage(msg, prices)
+			if !ok {
+				continue
+			}
+			publishTick(data)
+		}
+	}
+}
+
+// parseAlpacaMessage converts a raw alpacaMessage into the typed entity
+// matching its T discriminator, or ok=false for message types we don't
+// handle (e.g. subscription acks).
+func parseAlpacaMessage(msg alpacaMessage) (entity interface{}, ok bool) {
+	ts, _ := time.Parse(time.RFC3339Nano, msg.Timestamp)
+	switch msg.Type {
+	case "t": // trade
+		return Trade{Symbol: msg.Symbol, Price: msg.Price, Size: msg.Size, Timestamp: ts}, true
+	case "q": // quote
+		return Quote{Symbol: msg.Symbol, BidPrice: msg.BidPrice, AskPrice: msg.AskPrice, Timestamp: ts}, true
+	case "b": // bar
+		return Bar{Symbol: msg.Symbol, Open: msg.Open, Close: msg.Close, Timestamp: ts}, true
+	default:
+		return nil, false
+	}
+}
+
+// dispatchAlpacaMessage converts a raw Alpaca stream message into
+// StockData, tracking the last known price per symbol so change can be
+// computed across trade/quote/bar messages. Trade size becomes
+// StockData.Volume; quotes and bars carry no size and leave it zero.
+func dispatchAlpacaMessage(msg alpacaMessage, prices map[string]float64) (StockData, bool) {
+	entity, ok := parseAlpacaMessage(msg)
+	if !ok {
+		return StockData{}, false
+	}
+
+	var symbol string
+	var price, volume float64
+	var timestamp time.Time
+	switch e := entity.(type) {
+	case Trade:
+		symbol, price, volume, timestamp = e.Symbol, e.Price, e.Size, e.Timestamp
+	case Quote:
+		symbol, price, timestamp = e.Symbol, (e.BidPrice+e.AskPrice)/2, e.Timestamp
+	case Bar:
+		symbol, price, timestamp = e.Symbol, e.Close, e.Timestamp
+	}
+	if symbol == "" || price == 0 {
+		return StockData{}, false
+	}
+
+	previous, known := prices[symbol]
+	prices[symbol] = price
+	if !known || previous == 0 {
+		return StockData{}, false
+	}
+
+	change := ((price - previous) / previous) * 100
+	data := StockData{Symbol: symbol, Price: price, Change: change, Volume: volume}
+	if !timestamp.IsZero() {
+		data.Timestamp = timestamp.UnixNano()
+	}
+	return data, true
+}
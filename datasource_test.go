@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestDispatchAlpacaMessage(t *testing.T) {
+	prices := map[string]float64{}
+
+	// The first trade for a symbol only establishes a baseline price; no
+	// change can be computed yet.
+	first := alpacaMessage{Type: "t", Symbol: "AAPL", Price: 100, Size: 10, Timestamp: "2024-01-01T00:00:00Z"}
+	if _, ok := dispatchAlpacaMessage(first, prices); ok {
+		t.Fatal("expected no StockData on first observation of a symbol")
+	}
+
+	// A second trade computes change against the first and carries its size.
+	second := alpacaMessage{Type: "t", Symbol: "AAPL", Price: 110, Size: 5, Timestamp: "2024-01-01T00:00:01Z"}
+	data, ok := dispatchAlpacaMessage(second, prices)
+	if !ok {
+		t.Fatal("expected StockData on second observation")
+	}
+	if data.Price != 110 || data.Volume != 5 {
+		t.Errorf("got price=%v volume=%v, want price=110 volume=5", data.Price, data.Volume)
+	}
+	if want := 10.0; data.Change != want {
+		t.Errorf("got change=%v, want %v", data.Change, want)
+	}
+	if data.Timestamp == 0 {
+		t.Error("expected timestamp to be populated from the trade")
+	}
+
+	// Quote messages average bid/ask and carry no size.
+	quote := alpacaMessage{Type: "q", Symbol: "AAPL", BidPrice: 119, AskPrice: 121}
+	data, ok = dispatchAlpacaMessage(quote, prices)
+	if !ok {
+		t.Fatal("expected StockData for a quote message")
+	}
+	if data.Price != 120 || data.Volume != 0 {
+		t.Errorf("got price=%v volume=%v, want price=120 volume=0", data.Price, data.Volume)
+	}
+
+	// Unrecognized message types are ignored.
+	if _, ok := dispatchAlpacaMessage(alpacaMessage{Type: "x", Symbol: "AAPL", Price: 1}, prices); ok {
+		t.Fatal("expected an unknown message type to be ignored")
+	}
+}
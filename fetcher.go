@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+var (
+	cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "stock_tracker_cache_hits_total",
+		Help: "Number of quote cache hits.",
+	})
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "stock_tracker_cache_misses_total",
+		Help: "Number of quote cache misses.",
+	})
+	rateLimited = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "stock_tracker_rate_limited_total",
+		Help: "Number of fetches delayed or served stale due to the token-bucket limiter.",
+	})
+	upstreamErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "stock_tracker_upstream_errors_total",
+		Help: "Number of errors returned by the upstream Alpha Vantage API.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(cacheHits, cacheMisses, rateLimited, upstreamErrors)
+}
+
+// fetchRPM returns the Alpha Vantage requests-per-minute budget,
+// configurable via AV_RPM (default 5, matching the free tier).
+func fetchRPM() int {
+	v := strings.TrimSpace(os.Getenv("AV_RPM"))
+	if v == "" {
+		return 5
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 5
+	}
+	return n
+}
+
+// fetchLimiter throttles calls to fetchStock to fetchRPM requests per
+// minute across every symbol.
+var fetchLimiter = rate.NewLimiter(rate.Limit(float64(fetchRPM())/60.0), fetchRPM())
+
+// cachedQuote is the Redis-backed cache entry for a symbol's last fetch.
+type cachedQuote struct {
+	Data      StockData `json:"data"`
+	FetchedAt int64     `json:"fetched_at"` // unix nano
+}
+
+func (q cachedQuote) fresh(ttl time.Duration) bool {
+	return time.Since(time.Unix(0, q.FetchedAt)) < ttl
+}
+
+func quoteCacheKey(symbol string) string {
+	return "quote:" + symbol
+}
+
+func getCachedQuote(symbol string) (cachedQuote, bool) {
+	val, err := redisClient.Get(context.Background(), quoteCacheKey(symbol)).Result()
+	if err != nil {
+		return cachedQuote{}, false
+	}
+	var q cachedQuote
+	if err := json.Unmarshal([]byte(val), &q); err != nil {
+		return cachedQuote{}, false
+	}
+	return q, true
+}
+
+func setCachedQuote(symbol string, data StockData) {
+	q := cachedQuote{Data: data, FetchedAt: time.Now().UnixNano()}
+	payload, err := json.Marshal(q)
+	if err != nil {
+		return
+	}
+	redisClient.Set(context.Background(), quoteCacheKey(symbol), payload, 0)
+}
+
+// fetchCachedStock serves symbol's quote from the Redis cache when it's
+// still fresh (younger than the poll interval). On a miss it takes a
+// token from fetchLimiter and calls fetchStock; if the limiter has no
+// token available it instead serves a stale cached value, if any, and
+// kicks off a background revalidation, falling back to blocking (up to
+// ctx's deadline) only when no stale value exists.
+func fetchCachedStock(ctx context.Context, symbol string) (StockData, bool, error) {
+	ttl := getPollInterval()
+	cached, hasCached := getCachedQuote(symbol)
+	if hasCached && cached.fresh(ttl) {
+		cacheHits.Inc()
+		return cached.Data, true, nil
+	}
+	cacheMisses.Inc()
+
+	if fetchLimiter.Allow() {
+		return fetchAndCache(symbol)
+	}
+
+	rateLimited.Inc()
+	if hasCached {
+		go revalidate(symbol)
+		return cached.Data, true, nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, ttl)
+	defer cancel()
+	if err := fetchLimiter.Wait(waitCtx); err != nil {
+		return StockData{}, false, err
+	}
+	return fetchAndCache(symbol)
+}
+
+// fetchAndCache calls fetchStock and, on success, refreshes the cache.
+func fetchAndCache(symbol string) (StockData, bool, error) {
+	data, ok, err := fetchStock(symbol)
+	if err != nil {
+		upstreamErrors.Inc()
+		return StockData{}, false, err
+	}
+	if !ok {
+		return StockData{}, false, nil
+	}
+	setCachedQuote(symbol, data)
+	return data, true, nil
+}
+
+// revalidate waits for a limiter token (bounded by the poll interval)
+// and refreshes symbol's cache entry, used to implement
+// stale-while-revalidate without blocking the caller that got the stale
+// value.
+func revalidate(symbol string) {
+	ctx, cancel := context.WithTimeout(context.Background(), getPollInterval())
+	defer cancel()
+	if err := fetchLimiter.Wait(ctx); err != nil {
+		return
+	}
+	if _, _, err := fetchAndCache(symbol); err != nil {
+		log.Println("revalidate", symbol, err)
+	}
+}
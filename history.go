@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// historyRetention returns how long raw ticks are kept per symbol,
+// configurable via HISTORY_RETENTION (a Go duration string, e.g. "24h").
+func historyRetention() time.Duration {
+	v := strings.TrimSpace(os.Getenv("HISTORY_RETENTION"))
+	if v == "" {
+		return 24 * time.Hour
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// historyTick is a single raw sample stored in a symbol's history sorted
+// set, scored by its unix-nano timestamp.
+type historyTick struct {
+	Price  float64 `json:"price"`
+	Volume float64 `json:"volume,omitempty"`
+	Ts     int64   `json:"ts"`
+}
+
+// historyKey returns the Redis sorted-set key holding raw ticks for symbol.
+func historyKey(symbol string) string {
+	return "history:" + symbol
+}
+
+// recordHistory appends data to its symbol's history sorted set and
+// trims entries older than the configured retention window. It prefers
+// the source-reported timestamp (e.g. an Alpaca trade time) when present,
+// falling back to the time the server received the sample.
+func recordHistory(data StockData) {
+	now := time.Now()
+	ts := now.UnixNano()
+	if data.Timestamp != 0 {
+		ts = data.Timestamp
+	}
+	tick := historyTick{Price: data.Price, Volume: data.Volume, Ts: ts}
+	payload, err := json.Marshal(tick)
+	if err != nil {
+		return
+	}
+
+	ctx := context.Background()
+	key := historyKey(data.Symbol)
+	redisClient.ZAdd(ctx, key, &redis.Z{Score: float64(tick.Ts), Member: payload})
+
+	cutoff := now.Add(-historyRetention()).UnixNano()
+	redisClient.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(cutoff, 10))
+}
+
+// Candle is an OHLCV aggregate over one bucket of a history interval.
+type Candle struct {
+	T int64   `json:"t"`
+	O float64 `json:"o"`
+	H float64 `json:"h"`
+	L float64 `json:"l"`
+	C float64 `json:"c"`
+	V float64 `json:"v"`
+}
+
+// parseHistoryInterval maps the interval query param to a bucket
+// duration, defaulting to 1m.
+func parseHistoryInterval(v string) time.Duration {
+	switch v {
+	case "5m":
+		return 5 * time.Minute
+	case "1h":
+		return time.Hour
+	default:
+		return time.Minute
+	}
+}
+
+// aggregateCandles buckets ticks (assumed sorted by timestamp ascending)
+// into OHLCV candles of the given interval.
+func aggregateCandles(ticks []historyTick, interval time.Duration) []Candle {
+	buckets := make(map[int64]*Candle)
+	order := make([]int64, 0)
+
+	for _, t := range ticks {
+		bucketTs := t.Ts - (t.Ts % interval.Nanoseconds())
+		c, ok := buckets[bucketTs]
+		if !ok {
+			c = &Candle{T: bucketTs / int64(time.Second), O: t.Price, H: t.Price, L: t.Price, C: t.Price}
+			buckets[bucketTs] = c
+			order = append(order, bucketTs)
+		}
+		if t.Price > c.H {
+			c.H = t.Price
+		}
+		if t.Price < c.L {
+			c.L = t.Price
+		}
+		c.C = t.Price
+		c.V += t.Volume
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	candles := make([]Candle, 0, len(order))
+	for _, ts := range order {
+		candles = append(candles, *buckets[ts])
+	}
+	return candles
+}
+
+// handleHistory serves GET /history?symbol=AAPL&from=...&to=...&interval=1m|5m|1h,
+// returning OHLCV candles aggregated from the raw ticks stored for symbol.
+func handleHistory(c *gin.Context) {
+	symbol := strings.TrimSpace(c.Query("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol is required"})
+		return
+	}
+
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+			to = time.Unix(ts, 0)
+		}
+	}
+	from := to.Add(-historyRetention())
+	if v := c.Query("from"); v != "" {
+		if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+			from = time.Unix(ts, 0)
+		}
+	}
+	interval := parseHistoryInterval(c.Query("interval"))
+
+	raw, err := redisClient.ZRangeByScore(context.Background(), historyKey(symbol), &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.UnixNano(), 10),
+		Max: strconv.FormatInt(to.UnixNano(), 10),
+	}).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ticks := make([]historyTick, 0, len(raw))
+	for _, member := range raw {
+		var t historyTick
+		if err := json.Unmarshal([]byte(member), &t); err != nil {
+			continue
+		}
+		ticks = append(ticks, t)
+	}
+
+	c.JSON(http.StatusOK, aggregateCandles(ticks, interval))
+}
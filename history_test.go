@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHistoryInterval(t *testing.T) {
+	cases := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"5m", 5 * time.Minute},
+		{"1h", time.Hour},
+		{"1m", time.Minute},
+		{"", time.Minute},
+		{"bogus", time.Minute},
+	}
+	for _, tc := range cases {
+		if got := parseHistoryInterval(tc.in); got != tc.want {
+			t.Errorf("parseHistoryInterval(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestAggregateCandles(t *testing.T) {
+	const interval = time.Minute
+	ticks := []historyTick{
+		{Price: 100, Volume: 1, Ts: 0},
+		{Price: 105, Volume: 2, Ts: int64(10 * time.Second)},
+		{Price: 95, Volume: 3, Ts: int64(20 * time.Second)},
+		{Price: 102, Volume: 1, Ts: int64(time.Minute)}, // next bucket
+	}
+
+	candles := aggregateCandles(ticks, interval)
+	if len(candles) != 2 {
+		t.Fatalf("got %d candles, want 2", len(candles))
+	}
+
+	first := candles[0]
+	if first.T != 0 || first.O != 100 || first.H != 105 || first.L != 95 || first.C != 95 || first.V != 6 {
+		t.Errorf("first candle = %+v, want T=0 O=100 H=105 L=95 C=95 V=6", first)
+	}
+
+	second := candles[1]
+	if second.T != 60 || second.O != 102 || second.C != 102 || second.V != 1 {
+		t.Errorf("second candle = %+v, want T=60 O=102 C=102 V=1", second)
+	}
+}
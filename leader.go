@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	leaderKey     = "stock-tracker:leader"
+	stocksChannel = "stocks.updates"
+)
+
+// leaderLeaseDuration controls how long the leader lease lasts before it
+// must be renewed, configurable via LEADER_LEASE_SECONDS.
+func leaderLeaseDuration() time.Duration {
+	v := strings.TrimSpace(os.Getenv("LEADER_LEASE_SECONDS"))
+	if v == "" {
+		return 15 * time.Second
+	}
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds <= 0 {
+		return 15 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// instanceID identifies this process when competing for the leader
+// lease; it only needs to be unique among concurrently running
+// replicas, not across restarts.
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+var (
+	leaderMu sync.RWMutex
+	leading  bool
+)
+
+func setLeading(v bool) {
+	leaderMu.Lock()
+	leading = v
+	leaderMu.Unlock()
+}
+
+// isLeading reports whether this instance currently holds the
+// stock-tracker:leader lease and is running the MarketDataSource.
+func isLeading() bool {
+	leaderMu.RLock()
+	defer leaderMu.RUnlock()
+	return leading
+}
+
+// publishTick persists a tick produced by the leader's MarketDataSource
+// (leaderboard score + history) and publishes it on stocksChannel so
+// every replica, including this one, can forward it to its own local
+// clients via subscribeTicks.
+func publishTick(data StockData) {
+	ctx := context.Background()
+	redisClient.ZAdd(ctx, "leaderboard", &redis.Z{Score: data.Change, Member: data.Symbol})
+	recordHistory(data)
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Println("marshal tick:", err)
+		return
+	}
+	if err := redisClient.Publish(ctx, stocksChannel, payload).Err(); err != nil {
+		log.Println("publish tick:", err)
+	}
+}
+
+// subscribeTicks forwards every StockData published on stocksChannel to
+// this instance's local `updates` channel, where broadcaster delivers it
+// to local WebSocket clients and evaluates alert rules. It runs on every
+// replica, leader or not, until ctx is cancelled.
+func subscribeTicks(ctx context.Context) {
+	sub := redisClient.Subscribe(ctx, stocksChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var data StockData
+			if err := json.Unmarshal([]byte(msg.Payload), &data); err != nil {
+				continue
+			}
+			select {
+			case updates <- data:
+			default:
+			}
+		}
+	}
+}
+
+// renewLeaseScript extends the lease only if this instance still owns
+// it, preventing a slow renew from clobbering a lease acquired by
+// another instance in the meantime.
+var renewLeaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// runLeaderElection continuously attempts to acquire and renew the
+// stock-tracker:leader lease via SET NX PX. While holding it, this
+// instance runs source for symbols; losing the lease, or failing to
+// renew it, stops source until the lease is reacquired.
+func runLeaderElection(ctx context.Context, source MarketDataSource, symbols []string) {
+	lease := leaderLeaseDuration()
+	renewEvery := lease / 3
+	if renewEvery <= 0 {
+		renewEvery = time.Second
+	}
+
+	var sourceCancel context.CancelFunc
+	stopSource := func() {
+		if sourceCancel != nil {
+			sourceCancel()
+			sourceCancel = nil
+		}
+		setLeading(false)
+	}
+	startSource := func() {
+		if sourceCancel != nil {
+			return
+		}
+		var sourceCtx context.Context
+		sourceCtx, sourceCancel = context.WithCancel(ctx)
+		setLeading(true)
+		go func() {
+			if err := source.Start(sourceCtx, symbols); err != nil && sourceCtx.Err() == nil {
+				log.Println("data source stopped:", err)
+			}
+		}()
+	}
+	defer stopSource()
+
+	tryAcquire := func() bool {
+		ok, err := redisClient.SetNX(ctx, leaderKey, instanceID, lease).Result()
+		if err != nil {
+			log.Println("leader election:", err)
+			return false
+		}
+		return ok
+	}
+	renew := func() bool {
+		res, err := renewLeaseScript.Run(ctx, redisClient, []string{leaderKey}, instanceID, lease.Milliseconds()).Result()
+		if err != nil {
+			log.Println("leader renew:", err)
+			return false
+		}
+		n, _ := res.(int64)
+		return n == 1
+	}
+
+	ticker := time.NewTicker(renewEvery)
+	defer ticker.Stop()
+
+	for {
+		if isLeading() {
+			if !renew() {
+				log.Println("lost leader lease, stopping data source")
+				stopSource()
+			}
+		} else if tryAcquire() {
+			log.Println("acquired leader lease")
+			startSource()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleHealthz reports whether this instance currently holds the
+// leader lease, so operators can confirm exactly one replica is polling.
+func handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"instance_id": instanceID,
+		"leader":      isLeading(),
+	})
+}
@@ -15,20 +15,25 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// StockData represents stock price and variation data.
+// StockData represents stock price and variation data. Volume and
+// Timestamp are only populated by sources that report them (e.g. Alpaca
+// trades); pollers that only see a quote leave them zero.
 type StockData struct {
-	Symbol string  `json:"symbol"`
-	Price  float64 `json:"price"`
-	Change float64 `json:"change"`
+	Symbol    string  `json:"symbol"`
+	Price     float64 `json:"price"`
+	Change    float64 `json:"change"`
+	Volume    float64 `json:"volume,omitempty"`
+	Timestamp int64   `json:"timestamp,omitempty"` // unix nano, source-reported
 }
 
 // Global variables for Redis client, WebSocket upgrader, connected clients, and update channel.
 var (
 	redisClient *redis.Client
 	upgrader    = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
-	clients     = make(map[*websocket.Conn]bool)
+	clients     = make(map[*websocket.Conn]string) // conn -> authenticated user id
 	clientsMux  sync.Mutex
 	updates     = make(chan StockData, 128)
 )
@@ -80,6 +85,18 @@ func getPollInterval() time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// getDataSource selects the MarketDataSource implementation from the
+// DATA_SOURCE env var ("alphavantage" or "alpaca"), defaulting to
+// alphavantage.
+func getDataSource() MarketDataSource {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DATA_SOURCE"))) {
+	case "alpaca":
+		return NewAlpacaSource()
+	default:
+		return NewAlphaVantageSource()
+	}
+}
+
 // fetchStock fetches stock data for a symbol from Alpha Vantage.
 func fetchStock(symbol string) (StockData, bool, error) {
 	key := strings.TrimSpace(os.Getenv("ALPHA_VANTAGE_KEY"))
@@ -111,13 +128,6 @@ func fetchStock(symbol string) (StockData, bool, error) {
 	return StockData{Symbol: symbol, Price: current, Change: change}, true, nil
 }
 
-func pushUpdate(data StockData) {
-	select {
-	case updates <- data:
-	default:
-	}
-}
-
 func broadcaster() {
 	for data := range updates {
 		clientsMux.Lock()
@@ -128,43 +138,19 @@ func broadcaster() {
 			}
 		}
 		clientsMux.Unlock()
-	}
-}
-
-func fetchLoop() {
-	interval := getPollInterval()
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	update := func() {
-		for _, symbol := range getSymbols() {
-			data, ok, err := fetchStock(symbol)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			if !ok {
-				continue
-			}
-			redisClient.ZAdd(context.Background(), "leaderboard", &redis.Z{Score: data.Change, Member: data.Symbol})
-			pushUpdate(data)
-		}
-	}
-
-	update()
-	for range ticker.C {
-		update()
+		evaluateRules(data)
 	}
 }
 
 func handleWebSocket(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 	clientsMux.Lock()
-	clients[conn] = true
+	clients[conn] = userID
 	clientsMux.Unlock()
 	defer func() {
 		clientsMux.Lock()
@@ -181,18 +167,40 @@ func handleWebSocket(c *gin.Context) {
 
 // main sets up the server and starts goroutines.
 func main() {
-	if strings.TrimSpace(os.Getenv("ALPHA_VANTAGE_KEY")) == "" {
+	source := getDataSource()
+	if _, ok := source.(*AlphaVantageSource); ok && strings.TrimSpace(os.Getenv("ALPHA_VANTAGE_KEY")) == "" {
 		log.Fatal("missing ALPHA_VANTAGE_KEY")
 	}
+	if s, ok := source.(*AlpacaSource); ok && (s.apiKey == "" || s.apiSecret == "") {
+		log.Fatal("missing ALPACA_API_KEY or ALPACA_API_SECRET")
+	}
+	if strings.TrimSpace(os.Getenv("JWT_SECRET")) == "" {
+		log.Fatal("missing JWT_SECRET")
+	}
 
+	loadRules()
 	go broadcaster()
-	go fetchLoop()
-	r := gin.Default()
+
+	ctx := context.Background()
+	go subscribeTicks(ctx)
+	go runLeaderElection(ctx, source, getSymbols())
+
+	r := gin.New()
+	r.Use(redactedLogger(), gin.Recovery())
 	r.GET("/", func(c *gin.Context) { c.File("index.html") })
-	r.GET("/ws", handleWebSocket)
+	r.GET("/ws", requireWSAuth, handleWebSocket)
 	r.GET("/leaderboard", func(c *gin.Context) {
 		result := redisClient.ZRevRangeWithScores(context.Background(), "leaderboard", 0, -1)
 		c.JSON(200, result.Val())
 	})
+	r.GET("/history", handleHistory)
+	r.GET("/healthz", handleHealthz)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	alerts := r.Group("/alerts", requireAuth)
+	alerts.POST("", handleCreateRule)
+	alerts.GET("", handleListRules)
+	alerts.DELETE("/:id", handleDeleteRule)
+
 	r.Run(":8081")
 }
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rule is a user-defined alert condition evaluated against every
+// incoming StockData sample, e.g. {symbol:"AAPL", field:"change",
+// op:">=", value:5.0} or {field:"price", op:"cross_above", value:200}.
+type Rule struct {
+	ID              string  `json:"id"`
+	UserID          string  `json:"-"`
+	Symbol          string  `json:"symbol"`
+	Field           string  `json:"field"` // "price" or "change"
+	Op              string  `json:"op"`    // ">=", "<=", ">", "<", "==", "cross_above", "cross_below"
+	Value           float64 `json:"value"`
+	CooldownSeconds int     `json:"cooldown_seconds"`
+}
+
+const defaultCooldown = 30 * time.Second
+
+func (r Rule) cooldown() time.Duration {
+	if r.CooldownSeconds <= 0 {
+		return defaultCooldown
+	}
+	return time.Duration(r.CooldownSeconds) * time.Second
+}
+
+// rulesKey returns the Redis hash key storing userID's rules, keyed by
+// rule id with JSON-encoded Rule values.
+func rulesKey(userID string) string {
+	return "alerts:" + userID
+}
+
+// newRuleID generates a random hex identifier for a new rule.
+func newRuleID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strings.ReplaceAll(time.Now().String(), " ", "")
+	}
+	return hex.EncodeToString(b)
+}
+
+// ruleStore indexes rules by symbol for fast evaluation and tracks the
+// last fired time and last field value per rule so cross_above/
+// cross_below and cooldowns can be computed.
+type ruleStore struct {
+	mu        sync.RWMutex
+	bySymbol  map[string][]Rule
+	lastFired map[string]time.Time
+	lastValue map[string]float64
+}
+
+var rules = &ruleStore{
+	bySymbol:  make(map[string][]Rule),
+	lastFired: make(map[string]time.Time),
+	lastValue: make(map[string]float64),
+}
+
+func (s *ruleStore) add(r Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bySymbol[r.Symbol] = append(s.bySymbol[r.Symbol], r)
+}
+
+func (s *ruleStore) remove(userID, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for symbol, rs := range s.bySymbol {
+		for i, r := range rs {
+			if r.ID == id && r.UserID == userID {
+				s.bySymbol[symbol] = append(rs[:i], rs[i+1:]...)
+				delete(s.lastFired, id)
+				delete(s.lastValue, id)
+				return
+			}
+		}
+	}
+}
+
+func (s *ruleStore) forSymbol(symbol string) []Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Rule, len(s.bySymbol[symbol]))
+	copy(out, s.bySymbol[symbol])
+	return out
+}
+
+// tryFire reports whether rule should fire now given current, honoring
+// its cooldown and updating the store's last-value/last-fired state.
+func (s *ruleStore) tryFire(r Rule, current float64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, hasPrevious := s.lastValue[r.ID]
+	s.lastValue[r.ID] = current
+	if !ruleMatches(r, current, previous, hasPrevious) {
+		return false
+	}
+
+	if last, ok := s.lastFired[r.ID]; ok && time.Since(last) < r.cooldown() {
+		return false
+	}
+	s.lastFired[r.ID] = time.Now()
+	return true
+}
+
+// ruleMatches evaluates rule.Op against current (and previous, for the
+// cross_* operators).
+func ruleMatches(r Rule, current, previous float64, hasPrevious bool) bool {
+	switch r.Op {
+	case ">=":
+		return current >= r.Value
+	case "<=":
+		return current <= r.Value
+	case ">":
+		return current > r.Value
+	case "<":
+		return current < r.Value
+	case "==":
+		return current == r.Value
+	case "cross_above":
+		return hasPrevious && previous < r.Value && current >= r.Value
+	case "cross_below":
+		return hasPrevious && previous > r.Value && current <= r.Value
+	default:
+		return false
+	}
+}
+
+// fieldValue extracts the field Rule.Field refers to from data.
+func fieldValue(data StockData, field string) float64 {
+	if field == "change" {
+		return data.Change
+	}
+	return data.Price
+}
+
+// alertMessage is pushed to a user's WebSocket connections when one of
+// their rules fires.
+type alertMessage struct {
+	Type    string  `json:"type"`
+	RuleID  string  `json:"rule_id"`
+	Symbol  string  `json:"symbol"`
+	Field   string  `json:"field"`
+	Op      string  `json:"op"`
+	Value   float64 `json:"threshold"`
+	Current float64 `json:"current"`
+}
+
+// evaluateRules checks data against every registered rule for its
+// symbol and delivers alertMessages to the matching user's connections.
+func evaluateRules(data StockData) {
+	for _, r := range rules.forSymbol(data.Symbol) {
+		current := fieldValue(data, r.Field)
+		if !rules.tryFire(r, current) {
+			continue
+		}
+		deliverAlert(r.UserID, alertMessage{
+			Type:    "alert",
+			RuleID:  r.ID,
+			Symbol:  r.Symbol,
+			Field:   r.Field,
+			Op:      r.Op,
+			Value:   r.Value,
+			Current: current,
+		})
+	}
+}
+
+// deliverAlert writes msg to every connected client belonging to userID.
+func deliverAlert(userID string, msg alertMessage) {
+	clientsMux.Lock()
+	defer clientsMux.Unlock()
+	for conn, connUserID := range clients {
+		if connUserID != userID {
+			continue
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			conn.Close()
+			delete(clients, conn)
+		}
+	}
+}
+
+// loadRules restores every user's rules from Redis into the in-memory
+// index on startup.
+func loadRules() {
+	ctx := context.Background()
+	var cursor uint64
+	for {
+		keys, next, err := redisClient.Scan(ctx, cursor, "alerts:*", 100).Result()
+		if err != nil {
+			return
+		}
+		for _, key := range keys {
+			userID := strings.TrimPrefix(key, "alerts:")
+			values, err := redisClient.HGetAll(ctx, key).Result()
+			if err != nil {
+				continue
+			}
+			for _, payload := range values {
+				var r Rule
+				if err := json.Unmarshal([]byte(payload), &r); err != nil {
+					continue
+				}
+				r.UserID = userID
+				rules.add(r)
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return
+		}
+	}
+}
+
+// handleCreateRule handles POST /alerts, persisting a new rule for the
+// authenticated user.
+func handleCreateRule(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	var r Rule
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r.ID = newRuleID()
+	r.UserID = userID
+
+	payload, err := json.Marshal(r)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := redisClient.HSet(context.Background(), rulesKey(userID), r.ID, payload).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules.add(r)
+	c.JSON(http.StatusCreated, r)
+}
+
+// handleListRules handles GET /alerts, returning the authenticated
+// user's rules.
+func handleListRules(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	values, err := redisClient.HGetAll(context.Background(), rulesKey(userID)).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	out := make([]Rule, 0, len(values))
+	for _, payload := range values {
+		var r Rule
+		if err := json.Unmarshal([]byte(payload), &r); err != nil {
+			continue
+		}
+		out = append(out, r)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// handleDeleteRule handles DELETE /alerts/:id, removing the rule if it
+// belongs to the authenticated user.
+func handleDeleteRule(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	id := c.Param("id")
+
+	if err := redisClient.HDel(context.Background(), rulesKey(userID), id).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rules.remove(userID, id)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleMatches(t *testing.T) {
+	cases := []struct {
+		name              string
+		op                string
+		value             float64
+		current, previous float64
+		hasPrevious       bool
+		want              bool
+	}{
+		{"gte match", ">=", 100, 100, 0, false, true},
+		{"gte no match", ">=", 100, 99.9, 0, false, false},
+		{"lte match", "<=", 100, 100, 0, false, true},
+		{"gt match", ">", 100, 100.1, 0, false, true},
+		{"lt match", "<", 100, 99.9, 0, false, true},
+		{"eq match", "==", 100, 100, 0, false, true},
+		{"cross_above match", "cross_above", 200, 201, 199, true, true},
+		{"cross_above no previous", "cross_above", 200, 201, 0, false, false},
+		{"cross_above already above", "cross_above", 200, 201, 202, true, false},
+		{"cross_below match", "cross_below", 200, 199, 201, true, true},
+		{"cross_below already below", "cross_below", 200, 199, 198, true, false},
+		{"unknown op", "bogus", 100, 100, 0, false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := Rule{Op: tc.op, Value: tc.value}
+			got := ruleMatches(r, tc.current, tc.previous, tc.hasPrevious)
+			if got != tc.want {
+				t.Errorf("ruleMatches(%+v, %v, %v, %v) = %v, want %v",
+					r, tc.current, tc.previous, tc.hasPrevious, got, tc.want)
+			}
+		})
+	}
+}
+
+func newTestRuleStore() *ruleStore {
+	return &ruleStore{
+		bySymbol:  make(map[string][]Rule),
+		lastFired: make(map[string]time.Time),
+		lastValue: make(map[string]float64),
+	}
+}
+
+func TestRuleStoreTryFireCooldown(t *testing.T) {
+	s := newTestRuleStore()
+	rule := Rule{ID: "r1", Symbol: "AAPL", Field: "price", Op: ">=", Value: 100, CooldownSeconds: 60}
+
+	if !s.tryFire(rule, 101) {
+		t.Fatal("expected first fire to succeed")
+	}
+	if s.tryFire(rule, 102) {
+		t.Fatal("expected second fire within the cooldown window to be suppressed")
+	}
+
+	s.lastFired[rule.ID] = time.Now().Add(-2 * time.Minute)
+	if !s.tryFire(rule, 103) {
+		t.Fatal("expected fire to succeed once the cooldown has elapsed")
+	}
+}
+
+func TestRuleStoreTryFireNoMatchDoesNotConsumeCooldown(t *testing.T) {
+	s := newTestRuleStore()
+	rule := Rule{ID: "r1", Symbol: "AAPL", Field: "price", Op: ">=", Value: 100, CooldownSeconds: 60}
+
+	if s.tryFire(rule, 50) {
+		t.Fatal("expected no fire when the threshold isn't met")
+	}
+	if !s.tryFire(rule, 101) {
+		t.Fatal("expected fire once the threshold is met, unaffected by the earlier non-match")
+	}
+}